@@ -0,0 +1,124 @@
+// JSON to Go extension for VS Code.
+//
+// Date: March 2025
+// Author: Mario Petričko
+// GitHub: http://github.com/maracko/json-to-go-vsc
+//
+// Apache License
+// Version 2.0, January 2004
+// http://www.apache.org/licenses/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// typeListFlag collects repeated "-type" flags as well as comma-separated
+// values within a single "-type" flag, e.g. "-type Foo,Bar -type Baz".
+type typeListFlag []string
+
+func (t *typeListFlag) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *typeListFlag) Set(v string) error {
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			*t = append(*t, name)
+		}
+	}
+	return nil
+}
+
+// discoverExportedTypes scans every file in dir belonging to package
+// pkgName and returns the names of its exported struct types, for
+// -package mode. It uses go/parser and go/ast over the whole directory
+// rather than just the one file main.go's -file flag points at, since a
+// package is usually spread across several files.
+func discoverExportedTypes(dir, pkgName string) ([]string, error) {
+	fset := token.NewFileSet()
+
+	pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, ok := pkgs[pkgName]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found in %s", pkgName, dir)
+	}
+
+	var names []string
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range gen.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+
+				if _, ok := ts.Type.(*ast.StructType); !ok {
+					continue
+				}
+
+				if ts.Name.IsExported() {
+					names = append(names, ts.Name.Name)
+				}
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// schemaBundle is the stable JSON envelope the generator prints to stdout,
+// keyed by type name, whenever -format=bundle (the default).
+type schemaBundle struct {
+	Schemas map[string]json.RawMessage `json:"schemas"`
+}
+
+// writeSchemaFiles splits a schemaBundle into one "<Type>.schema.json" file
+// per type under outDir, for -format=files, and returns a short summary
+// describing what was written.
+func writeSchemaFiles(raw string, outDir string) (string, error) {
+	var bundle schemaBundle
+	if err := json.Unmarshal([]byte(raw), &bundle); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(outDir, 0777); err != nil {
+		return "", err
+	}
+
+	written := make([]string, 0, len(bundle.Schemas))
+	for typeName, schema := range bundle.Schemas {
+		path := filepath.Join(outDir, typeName+".schema.json")
+		if err := os.WriteFile(path, schema, 0666); err != nil {
+			return "", err
+		}
+		written = append(written, path)
+	}
+
+	summary, err := json.Marshal(struct {
+		Written []string `json:"written"`
+	}{Written: written})
+	if err != nil {
+		return "", err
+	}
+
+	return string(summary), nil
+}
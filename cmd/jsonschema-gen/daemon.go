@@ -0,0 +1,283 @@
+// JSON to Go extension for VS Code.
+//
+// Date: March 2025
+// Author: Mario Petričko
+// GitHub: http://github.com/maracko/json-to-go-vsc
+//
+// Apache License
+// Version 2.0, January 2004
+// http://www.apache.org/licenses/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const cacheDirName = "json-to-go-vsc"
+
+// Only the -serve side of the daemon protocol lives here. The VS Code
+// extension's client that spawns this binary once per workspace and talks
+// to it over this protocol is TypeScript living in the extension's own
+// source tree, which this module (cmd/jsonschema-gen) does not contain.
+
+// serveRequest is one line of the -serve protocol read from stdin.
+type serveRequest struct {
+	File string `json:"file"`
+	Type string `json:"type"`
+}
+
+// serveResponse is one line written back to stdout: either Schema is set,
+// or Error is, never both.
+type serveResponse struct {
+	Schema json.RawMessage `json:"schema,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// runServe keeps the process alive, reading one serveRequest per line from
+// stdin and writing one serveResponse per line to stdout, reusing a
+// compiled generator binary across requests whenever the module, the
+// target file and the requested type haven't changed.
+func runServe() error {
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	for in.Scan() {
+		line := in.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var req serveRequest
+		resp := serveResponse{}
+
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = err.Error()
+		} else if schema, err := generateCached(req.File, req.Type); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Schema = json.RawMessage(schema)
+		}
+
+		if err := json.NewEncoder(out).Encode(resp); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return in.Err()
+}
+
+// generateCached resolves file/type the same way the one-shot path does,
+// then reuses a previously compiled generator binary if one exists for the
+// same (module dir, go.sum, target package directory, type name) key,
+// building one otherwise.
+//
+// The on-disk cache is only safe when every type the generated program
+// reflects over lives in the target file's own package: cacheKey hashes
+// every Go file in that package's directory, since a field routinely
+// references a sibling type declared in another file of the same package.
+// It can't account for a qualified "-type pkg.Type" target declared in a
+// different package without hashing an open-ended set of other packages'
+// source files, so those targets skip the on-disk cache instead and are
+// built fresh into a scratch binary for every request. A bare type name is
+// always cached: its fields are not inspected, so edits to other packages
+// those fields reference are not detected by the key.
+func generateCached(file, typeName string) (string, error) {
+	i, modDir, err := resolveTarget(file, typeName)
+	if err != nil {
+		return "", err
+	}
+
+	// The -serve protocol carries no Reflector options, so requests get the
+	// same defaults as the one-shot CLI flags.
+	i.Reflector = reflectorConfig{Draft: "2020-12", KeyNamer: "as-is"}
+
+	if hasQualifiedTarget(i.Types) {
+		return buildAndRunUncached(i)
+	}
+
+	key, err := cacheKey(modDir, filepath.Dir(i.FilePath), typeName)
+	if err != nil {
+		return "", err
+	}
+
+	binPath, err := cachedBinaryPath(key)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(binPath); err != nil {
+		if err := buildCachedBinary(i, binPath); err != nil {
+			return "", err
+		}
+	}
+
+	return runCachedBinary(binPath)
+}
+
+// hasQualifiedTarget reports whether any of targets names a type declared
+// outside the target file's own package ("-type pkg.Type"), or carries
+// Extra packages its fields reference (only qualified targets have Extra).
+func hasQualifiedTarget(targets []typeTarget) bool {
+	for _, t := range targets {
+		if t.ImportPath != "" || len(t.Extra) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// buildAndRunUncached builds i into a throwaway binary under os.TempDir
+// (deliberately outside the render scratch directory buildCachedBinary
+// itself cleans up) and runs it once, without touching the on-disk cache.
+// Used for qualified targets, where cacheKey can't account for every file
+// that feeds into the generated program.
+func buildAndRunUncached(i importInfo) (string, error) {
+	binDir, err := os.MkdirTemp("", tmpDirName)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(binDir)
+
+	binName := "generator-bin"
+	if runtime.GOOS == "windows" {
+		binName += ".exe"
+	}
+	binPath := filepath.Join(binDir, binName)
+
+	if err := buildCachedBinary(i, binPath); err != nil {
+		return "", err
+	}
+
+	return runCachedBinary(binPath)
+}
+
+// cacheKey hashes the module directory, its go.sum (if any), every Go file
+// in the target's package directory and the requested type name, so a
+// cached binary is only reused when none of those have changed since it
+// was built. It hashes the whole package directory rather than just the
+// named file because a struct's fields routinely reference sibling types
+// declared in other files of the same package; hashing only the one file
+// named by -file would miss edits to those.
+func cacheKey(modDir, pkgDir, typeName string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintln(h, modDir)
+	fmt.Fprintln(h, typeName)
+
+	if sum, err := os.ReadFile(filepath.Join(modDir, "go.sum")); err == nil {
+		h.Write(sum)
+	}
+
+	entries, err := os.ReadDir(pkgDir)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".go") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(pkgDir, name))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(h, name)
+		h.Write(data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func cachedBinaryPath(key string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(base, cacheDirName)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+
+	name := key
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+// buildCachedBinary renders the generator template into a scratch
+// directory, compiles it and moves the resulting binary into the cache at
+// binPath. Unlike generateOnce (which "go run"s a throwaway program), this
+// produces a binary that can be re-run for later requests without paying
+// for compilation again.
+func buildCachedBinary(i importInfo, binPath string) error {
+	fileDir := filepath.Dir(i.FilePath)
+	tmpDir := filepath.Join(fileDir, tmpDirName)
+	tmpMain := filepath.Join(tmpDir, "main.go")
+
+	if err := renderGenerator(i, tmpDir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpBin := binPath + ".tmp"
+
+	cmd := exec.Command("go", "build", "-o", tmpBin, tmpMain)
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GO111MODULE=auto")
+
+	stdErr := new(bytes.Buffer)
+	cmd.Stderr = stdErr
+
+	if err := cmd.Run(); err != nil {
+		msg := err.Error()
+		if stdErr.Len() > 0 {
+			msg += "\n" + stdErr.String()
+		}
+		return fmt.Errorf("%s", msg)
+	}
+
+	return os.Rename(tmpBin, binPath)
+}
+
+func runCachedBinary(binPath string) (string, error) {
+	stdOut := new(bytes.Buffer)
+	stdErr := new(bytes.Buffer)
+
+	cmd := exec.Command(binPath)
+	cmd.Stdout = stdOut
+	cmd.Stderr = stdErr
+
+	if err := cmd.Run(); err != nil {
+		msg := err.Error()
+		if stdErr.Len() > 0 {
+			msg += "\n" + stdErr.String()
+		}
+		return "", fmt.Errorf("%s", msg)
+	}
+
+	return stdOut.String(), nil
+}
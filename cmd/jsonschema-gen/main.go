@@ -23,6 +23,10 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 //go:embed all:vendored
@@ -45,90 +49,253 @@ type importInfo struct {
 	RelativePath string
 	FilePath     string
 	TypeName     string
+	// Types holds every type to generate a schema for. TypeName is kept
+	// as Types[0].Name for template code and callers that only care
+	// about a single type (e.g. the -serve protocol).
+	Types []typeTarget
+
+	// Imports is every foreign package (deduplicated by path) that a
+	// qualified "-type pkg.Type" in Types names directly. The template
+	// imports these alongside the target file's own package. Packages
+	// reached only through a field (typeTarget.Extra) are not included;
+	// see foreignImports.
+	Imports []importRef
+
+	Reflector reflectorConfig
+}
+
+// typeTarget is one type to reflect. ImportPath/Alias are only set when
+// the type was given as "pkg/path.TypeName" (or was found by following a
+// field reference into another package); a zero ImportPath means Name
+// lives in the file's own package (ImportName).
+type typeTarget struct {
+	Name       string
+	ImportPath string
+	Alias      string
+	Extra      []importRef
 }
 
+// reflectorConfig carries the jsonschema.Reflector options the template
+// sets on the Reflector before calling Reflect, so generated schemas don't
+// have to rely on the library's zero-value defaults.
+type reflectorConfig struct {
+	Draft                     string
+	BaseID                    string
+	ExpandStruct              bool
+	AllowAdditionalProperties bool
+	DoNotReference            bool
+	WithComments              bool
+	AssignAnchor              bool
+	KeyNamer                  string
+
+	// BaseDir and PackageDir are only used when WithComments is set, to
+	// call jsonschema.Reflector.AddGoComments(baseDir, packageDir).
+	BaseDir    string
+	PackageDir string
+}
+
+var validDrafts = map[string]bool{"2020-12": true, "2019-09": true, "7": true}
+var validKeyNamers = map[string]bool{"snake": true, "camel": true, "kebab": true, "as-is": true}
+
 func main() {
+	var typeNames typeListFlag
+
 	var (
-		filePath   = flag.String("file", "", "Path to the Go source file")
-		symbolName = flag.String("type", "", "Name of the type to generate schema for")
+		filePath             = flag.String("file", "", "Path to the Go source file")
+		wholePackage         = flag.Bool("package", false, "Generate schemas for every exported struct type in the file's package")
+		format               = flag.String("format", "bundle", `Output format: "bundle" (single JSON object on stdout) or "files" (one file per type under -out)`)
+		outDir               = flag.String("out", "", "Directory to write per-type schema files to when -format=files (defaults to the target file's directory)")
+		serve                = flag.Bool("serve", false, "Keep the process alive and serve generation requests over stdin/stdout")
+		draft                = flag.String("draft", "2020-12", `JSON Schema draft to target: "2020-12", "2019-09", or "7"`)
+		baseID               = flag.String("base-id", "", "Base URI used as the schema's $id")
+		expandStruct         = flag.Bool("expand-struct", false, "Expand the root struct inline instead of emitting a $ref to a definition")
+		allowAdditionalProps = flag.Bool("allow-additional-properties", false, "Allow additional properties on generated object schemas")
+		doNotReference       = flag.Bool("do-not-reference", false, "Inline every type instead of emitting $ref definitions")
+		withComments         = flag.Bool("with-comments", false, "Use godoc comments on the target struct as schema descriptions")
+		assignAnchor         = flag.Bool("assign-anchor", false, "Assign a $anchor to every definition")
+		keyNamer             = flag.String("key-namer", "as-is", "Field name casing for schema keys: snake, camel, kebab, or as-is")
 	)
+	flag.Var(&typeNames, "type", "Name of the type to generate schema for (repeatable, or comma-separated)")
 
 	flag.Parse()
 
-	if *filePath == "" || *symbolName == "" {
+	if *serve {
+		must(runServe())
+		return
+	}
+
+	if *filePath == "" || (!*wholePackage && len(typeNames) == 0) {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if !validDrafts[*draft] {
+		fail(fmt.Sprintf("invalid -draft %q: must be 2020-12, 2019-09, or 7", *draft))
+	}
+	if !validKeyNamers[*keyNamer] {
+		fail(fmt.Sprintf("invalid -key-namer %q: must be snake, camel, kebab, or as-is", *keyNamer))
+	}
 
-	absPath, err := filepath.Abs(*filePath)
+	i, modDir, err := resolveTargets(*filePath, []string(typeNames), *wholePackage)
 	if err != nil {
 		fail(err.Error())
 	}
 
+	i.Reflector = reflectorConfig{
+		Draft:                     *draft,
+		BaseID:                    *baseID,
+		ExpandStruct:              *expandStruct,
+		AllowAdditionalProperties: *allowAdditionalProps,
+		DoNotReference:            *doNotReference,
+		WithComments:              *withComments,
+		AssignAnchor:              *assignAnchor,
+		KeyNamer:                  *keyNamer,
+		BaseDir:                   modDir,
+		PackageDir:                filepath.Dir(i.FilePath),
+	}
+
+	out, err := generateOnce(i)
+	if err != nil {
+		fail(err.Error())
+	}
+
+	if *format == "files" {
+		dir := *outDir
+		if dir == "" {
+			dir = filepath.Dir(i.FilePath)
+		}
+
+		out, err = writeSchemaFiles(out, dir)
+		if err != nil {
+			fail(err.Error())
+		}
+	}
+
+	fmt.Printf("%s", out)
+}
+
+// resolveTarget figures out the importInfo for a -file/-type pair: the
+// owning module (honoring go.work/replace directives), its package name,
+// and the import path the generated program needs to use.
+// resolveTarget is a convenience wrapper around resolveTargets for callers
+// that only ever want a single type, such as the -serve protocol.
+func resolveTarget(filePath, symbolName string) (i importInfo, modDir string, err error) {
+	return resolveTargets(filePath, []string{symbolName}, false)
+}
+
+// resolveTargets figures out the importInfo for one or more types in a
+// file, or, when wholePackage is set, for every exported struct type in
+// that file's package.
+func resolveTargets(filePath string, typeNames []string, wholePackage bool) (i importInfo, modDir string, err error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return importInfo{}, "", err
+	}
+
 	fileName := filepath.Base(absPath)
 	fileDir := filepath.Dir(absPath)
 
 	pkgName, err := parsePkgName(absPath)
 	if err != nil {
-		fail(err.Error())
+		return importInfo{}, "", err
 	}
 
-	modDir := findGoMod(fileDir)
-	if modDir == "" {
-		fail("go.mod not found for file " + fileDir)
+	if wholePackage {
+		if len(typeNames) > 0 {
+			return importInfo{}, "", fmt.Errorf("-type cannot be combined with -package; pass one or the other")
+		}
+
+		typeNames, err = discoverExportedTypes(fileDir, pkgName)
+		if err != nil {
+			return importInfo{}, "", err
+		}
+	}
+	if len(typeNames) == 0 {
+		return importInfo{}, "", fmt.Errorf("no exported struct types found in package %s", pkgName)
 	}
-	modName, goVer, err := parseGoMod(modDir)
+
+	mod, err := resolveModule(fileDir)
 	if err != nil {
-		fail(err.Error())
+		return importInfo{}, "", err
 	}
 
-	if modName == "" {
-		fail("module name not found in go.mod")
+	if mod.modPath == "" {
+		return importInfo{}, "", fmt.Errorf("module name not found in go.mod")
 	}
-	if goVer < "1.18" {
-		fail("go mod version must be at least 1.18")
+	if goVersionLess(mod.goVer, "1.18") {
+		return importInfo{}, "", fmt.Errorf("go mod version must be at least 1.18")
 	}
 
-	relPath, err := filepath.Rel(modDir, fileDir)
+	importPath, relPath, err := mod.importPathFor(fileDir)
 	if err != nil {
-		fail(err.Error())
-	}
-	if relPath == "." {
-		relPath = ""
+		return importInfo{}, "", err
 	}
 
-	importPath := modName
-	if relPath != "" {
-		importPath += "/" + relPath
+	targets := make([]typeTarget, len(typeNames))
+	for idx, spec := range typeNames {
+		name, qualifiedPkg := parseTypeSpec(spec)
+		tt := typeTarget{Name: name}
+
+		if qualifiedPkg != "" {
+			resolvedPath, alias, extra, err := resolvePackageType(fileDir, qualifiedPkg, name)
+			if err != nil {
+				return importInfo{}, "", err
+			}
+			tt.ImportPath = resolvedPath
+			tt.Alias = alias
+			tt.Extra = extra
+		}
+
+		targets[idx] = tt
 	}
 
-	i := importInfo{
+	return importInfo{
 		ImportName:   importPath,
 		FileName:     fileName,
 		PackageName:  pkgName,
 		RelativePath: relPath,
-		TypeName:     *symbolName,
-		ModuleName:   modName,
+		TypeName:     targets[0].Name,
+		Types:        targets,
+		Imports:      foreignImports(targets),
+		ModuleName:   mod.modPath,
 		FilePath:     filepath.Join(fileDir, fileName),
+	}, mod.modDir, nil
+}
+
+// foreignImports flattens every qualified target's own import into a
+// single list, deduplicated by package path, so the template only has to
+// emit one import block. It deliberately excludes typeTarget.Extra: those
+// are packages a target's *fields* reference, not packages the generated
+// program's source names directly, so importing them would just produce
+// "imported and not used" (Extra still matters to generateCached, which
+// uses it to decide whether the on-disk binary cache is safe to reuse).
+func foreignImports(targets []typeTarget) []importRef {
+	seen := map[string]bool{}
+	var refs []importRef
+
+	for _, tt := range targets {
+		if tt.ImportPath == "" || seen[tt.ImportPath] {
+			continue
+		}
+		seen[tt.ImportPath] = true
+		refs = append(refs, importRef{Path: tt.ImportPath, Alias: tt.Alias})
 	}
 
+	return refs
+}
+
+// generateOnce renders the generator template for i into a scratch
+// directory next to the target file, runs it with "go run" and returns its
+// stdout. This is the original one-shot path, kept as-is so the CLI
+// contract doesn't change; -serve uses the cached, compiled path instead.
+func generateOnce(i importInfo) (string, error) {
+	fileDir := filepath.Dir(i.FilePath)
 	tmpDir := filepath.Join(fileDir, tmpDirName)
 	tmpMain := filepath.Join(tmpDir, "main.go")
 
-	must(os.MkdirAll(tmpDir, 0777))
-
-	f, err := os.Create(tmpMain)
-	if err != nil {
-		fail(err.Error())
+	if err := renderGenerator(i, tmpDir); err != nil {
+		return "", err
 	}
-	defer f.Close()
-
-	mainTemplate := template.Must(template.New(mainTemplName).ParseFS(embedFS, mainTemplName))
-	must(mainTemplate.Execute(f, i))
-
-	prefix := filepath.Join(importPath, tmpDirName, vendorDirName)
-	must(copyDeps(tmpDir))
-	must(renameImports(tmpDir, prefix))
+	defer os.RemoveAll(tmpDir)
 
 	stdOut := new(bytes.Buffer)
 	stdErr := new(bytes.Buffer)
@@ -139,21 +306,46 @@ func main() {
 	cmd.Stderr = stdErr
 	cmd.Env = append(
 		os.Environ(),
-		"GOWORK=off",
 		"GO111MODULE=auto",
 	)
 
-	if err = cmd.Run(); err != nil {
+	if err := cmd.Run(); err != nil {
 		msg := err.Error()
 		if stdErr.Len() > 0 {
 			msg += "\n" + stdErr.String()
 		}
-		fail(msg)
+		return "", fmt.Errorf("%s", msg)
+	}
+
+	return stdOut.String(), nil
+}
+
+// renderGenerator writes the template-rendered main.go plus a copy of the
+// vendored dependencies into dir, ready to be "go run" or "go build"-ed.
+func renderGenerator(i importInfo, dir string) error {
+	tmpMain := filepath.Join(dir, "main.go")
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	f, err := os.Create(tmpMain)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mainTemplate := template.Must(template.New(mainTemplName).ParseFS(embedFS, mainTemplName))
+	if err := mainTemplate.Execute(f, i); err != nil {
+		return err
 	}
 
-	must(os.RemoveAll(tmpDir))
+	prefix := filepath.Join(i.ImportName, tmpDirName, vendorDirName)
+	if err := copyDeps(dir); err != nil {
+		return err
+	}
 
-	fmt.Printf("%s", stdOut.String())
+	return renameImports(dir, prefix)
 }
 
 func copyDeps(dst string) error {
@@ -212,6 +404,233 @@ func renameImports(root, prefix string) error {
 	})
 }
 
+// resolvedModule describes the module (or workspace member) that owns the
+// file we're generating a schema for, plus enough of its go.mod to honor
+// replace/exclude/retract directives when computing import paths.
+type resolvedModule struct {
+	modDir  string
+	modPath string
+	goVer   string
+
+	replace []*modfile.Replace
+	exclude []*modfile.Exclude
+	retract []*modfile.Retract
+}
+
+// resolveModule walks up from dir looking for a go.work first, then a
+// go.mod, and parses whichever it finds with golang.org/x/mod/modfile so
+// that block-form directives, comments and multi-line "go" stanzas are
+// handled the same way the go command itself would.
+func resolveModule(dir string) (*resolvedModule, error) {
+	if workPath := findGoWork(dir); workPath != "" {
+		return resolveWorkspaceModule(workPath, dir)
+	}
+
+	modDir := findGoMod(dir)
+	if modDir == "" {
+		return nil, fmt.Errorf("go.mod not found for file %s", dir)
+	}
+
+	mf, err := parseModFile(modDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return moduleFromFile(modDir, mf), nil
+}
+
+// resolveWorkspaceModule parses the go.work at workPath and finds the
+// workspace member (a "use" directory) that contains dir, so a type living
+// in one member can still be resolved when another member is the one
+// importing it.
+func resolveWorkspaceModule(workPath, dir string) (*resolvedModule, error) {
+	data, err := os.ReadFile(workPath)
+	if err != nil {
+		return nil, err
+	}
+
+	wf, err := modfile.ParseWork(workPath, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir := filepath.Dir(workPath)
+
+	var member *resolvedModule
+	for _, use := range wf.Use {
+		useDir := filepath.Clean(filepath.Join(workDir, use.Path))
+
+		rel, err := filepath.Rel(useDir, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		mf, err := parseModFile(useDir)
+		if err != nil {
+			return nil, err
+		}
+
+		m := moduleFromFile(useDir, mf)
+		// Workspace-level replace directives take priority over the
+		// member module's own replace directives, same as "go build"
+		// run from within a go.work.
+		m.replace = append(append([]*modfile.Replace{}, wf.Replace...), m.replace...)
+		if wf.Go != nil && goVersionLess(m.goVer, wf.Go.Version) {
+			m.goVer = wf.Go.Version
+		}
+
+		if member == nil || len(useDir) > len(member.modDir) {
+			member = m
+		}
+	}
+
+	if member == nil {
+		return nil, fmt.Errorf("no workspace member in %s owns %s", workPath, dir)
+	}
+
+	return member, nil
+}
+
+func parseModFile(modDir string) (*modfile.File, error) {
+	path := filepath.Join(modDir, "go.mod")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return modfile.Parse(path, data, nil)
+}
+
+func moduleFromFile(modDir string, mf *modfile.File) *resolvedModule {
+	m := &resolvedModule{modDir: modDir, replace: mf.Replace, exclude: mf.Exclude, retract: mf.Retract}
+
+	if mf.Module != nil {
+		m.modPath = mf.Module.Mod.Path
+	}
+	if mf.Go != nil {
+		m.goVer = mf.Go.Version
+	}
+
+	return m
+}
+
+// importPathFor computes the import path of the package containing fileDir,
+// honoring any replace directive that redirects it to a local filesystem
+// path. Without this, a file living under a "replace foo => ../foo-fork"
+// target would be imported as if it were part of the replacing module,
+// which silently doesn't compile.
+func (m *resolvedModule) importPathFor(fileDir string) (importPath, relPath string, err error) {
+	for _, r := range m.replace {
+		if !isLocalReplace(r.New) {
+			continue
+		}
+
+		replDir := filepath.Clean(filepath.Join(m.modDir, r.New.Path))
+
+		rel, relErr := filepath.Rel(replDir, fileDir)
+		if relErr != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		if err := m.checkExcludedAndRetracted(r); err != nil {
+			return "", "", err
+		}
+
+		if rel == "." {
+			rel = ""
+		}
+
+		importPath = r.Old.Path
+		if rel != "" {
+			importPath += "/" + rel
+		}
+
+		return importPath, rel, nil
+	}
+
+	rel, err := filepath.Rel(m.modDir, fileDir)
+	if err != nil {
+		return "", "", err
+	}
+	if rel == "." {
+		rel = ""
+	}
+
+	importPath = m.modPath
+	if rel != "" {
+		importPath += "/" + rel
+	}
+
+	return importPath, rel, nil
+}
+
+// checkExcludedAndRetracted refuses to run when the module a local replace
+// points at is exclude-d, or when the version it's replacing is retracted
+// by that module's own go.mod (looked up from the module cache, since a
+// local replace target has no version of its own).
+func (m *resolvedModule) checkExcludedAndRetracted(r *modfile.Replace) error {
+	for _, ex := range m.exclude {
+		if ex.Mod.Path == r.Old.Path && ex.Mod.Version == r.Old.Version {
+			return fmt.Errorf("module %s is excluded in go.mod", r.Old.Path)
+		}
+	}
+
+	if r.Old.Version == "" {
+		return nil
+	}
+
+	cacheDir, err := module.EscapePath(r.Old.Path)
+	if err != nil {
+		return nil
+	}
+	cacheVer, err := module.EscapeVersion(r.Old.Version)
+	if err != nil {
+		return nil
+	}
+
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		out, err := exec.Command("go", "env", "GOMODCACHE").Output()
+		if err != nil {
+			return nil
+		}
+		modCache = strings.TrimSpace(string(out))
+	}
+
+	gomodPath := filepath.Join(modCache, cacheDir+"@"+cacheVer, "go.mod")
+	data, err := os.ReadFile(gomodPath)
+	if err != nil {
+		// Not in the module cache; nothing to check.
+		return nil
+	}
+
+	mf, err := modfile.Parse(gomodPath, data, nil)
+	if err != nil {
+		return nil
+	}
+
+	for _, ret := range mf.Retract {
+		if semver.Compare(r.Old.Version, ret.Low) >= 0 && semver.Compare(r.Old.Version, ret.High) <= 0 {
+			return fmt.Errorf("module %s version %s is retracted: %s", r.Old.Path, r.Old.Version, ret.Rationale)
+		}
+	}
+
+	return nil
+}
+
+// goVersionLess reports whether a is a lower Go version than b, comparing
+// as semver rather than as strings (a plain string comparison gets e.g.
+// "1.9" > "1.10" wrong). Both a and b are go.mod "go" directive versions,
+// which don't carry the "v" prefix semver.Compare expects.
+func goVersionLess(a, b string) bool {
+	return semver.Compare("v"+a, "v"+b) < 0
+}
+
+func isLocalReplace(v module.Version) bool {
+	return strings.HasPrefix(v.Path, "./") || strings.HasPrefix(v.Path, "../") || filepath.IsAbs(v.Path)
+}
+
 func findGoMod(dir string) string {
 	for {
 		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
@@ -225,25 +644,20 @@ func findGoMod(dir string) string {
 	}
 }
 
-func parseGoMod(modDir string) (modName, goVer string, err error) {
-	data, err := os.ReadFile(filepath.Join(modDir, "go.mod"))
-	if err != nil {
-		return
-	}
-
-	for _, line := range strings.Split(string(data), "\n") {
-		if strings.HasPrefix(line, "module ") {
-			modName = strings.TrimSpace(strings.TrimPrefix(line, "module "))
-		} else if strings.HasPrefix(line, "go ") {
-			goVer = strings.TrimSpace(strings.TrimPrefix(line, "go "))
+// findGoWork walks up from dir looking for a go.work, the same way the go
+// command picks a workspace root. It's checked before go.mod since a
+// workspace root normally sits above its member modules.
+func findGoWork(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+			return filepath.Join(dir, "go.work")
 		}
-
-		if goVer != "" && modName != "" {
-			break
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
 		}
+		dir = parent
 	}
-
-	return
 }
 
 func parsePkgName(file string) (pkgName string, err error) {
@@ -0,0 +1,133 @@
+// JSON to Go extension for VS Code.
+//
+// Date: March 2025
+// Author: Mario Petričko
+// GitHub: http://github.com/maracko/json-to-go-vsc
+//
+// Apache License
+// Version 2.0, January 2004
+// http://www.apache.org/licenses/
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// exportedIdent matches a bare exported Go identifier, used to tell a
+// qualified "-type pkg/path.TypeName" apart from a vanity import path that
+// merely happens to contain a dot (e.g. "gopkg.in/yaml.v3").
+var exportedIdent = regexp.MustCompile(`^[A-Z]\w*$`)
+
+// importRef is a package path plus the alias it is known by. The template
+// imports the packages of qualified targets under these aliases and names
+// the aliases when reflecting; the refs in typeTarget.Extra are not imported.
+type importRef struct {
+	Path  string
+	Alias string
+}
+
+// parseTypeSpec splits a "-type" value into a bare type name and, if the
+// value was qualified as "pkg/path.TypeName", the package path it lives in.
+// Splitting on the last dot works even for vanity import paths with dots
+// of their own, since a Go type name never contains one.
+func parseTypeSpec(spec string) (name, pkgPath string) {
+	idx := strings.LastIndex(spec, ".")
+	if idx < 0 {
+		return spec, ""
+	}
+
+	candidate := spec[idx+1:]
+	if !exportedIdent.MatchString(candidate) {
+		return spec, ""
+	}
+
+	return candidate, spec[:idx]
+}
+
+// resolvePackageType loads pkgPath (relative to fileDir's module) with
+// golang.org/x/tools/go/packages and locates typeName in it, returning the
+// package's real import path and name (which can differ from the path's
+// last segment) plus every other package typeName's fields reference. The
+// latter are not imported by the generated program; generateCached uses
+// them to decide whether the on-disk binary cache can be reused.
+func resolvePackageType(fileDir, pkgPath, typeName string) (resolvedPath, alias string, extra []importRef, err error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedTypes | packages.NeedSyntax,
+		Dir:  fileDir,
+	}
+
+	pkgs, err := packages.Load(cfg, pkgPath)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(pkgs) == 0 {
+		return "", "", nil, fmt.Errorf("package %s not found", pkgPath)
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return "", "", nil, pkg.Errors[0]
+	}
+	if pkg.Types == nil {
+		return "", "", nil, fmt.Errorf("package %s has no type information", pkgPath)
+	}
+
+	obj := pkg.Types.Scope().Lookup(typeName)
+	if obj == nil {
+		return "", "", nil, fmt.Errorf("type %s not found in package %s", typeName, pkgPath)
+	}
+
+	return pkg.PkgPath, pkg.Name, collectExternalImports(pkg.Types, obj), nil
+}
+
+// collectExternalImports walks obj's type recursively (through pointers,
+// slices, arrays, maps and nested structs) and returns every distinct
+// package other than primary that it references. seenTypes is keyed by the named type's string form so that
+// self-referential or mutually-recursive structs (a linked list, a tree
+// node, ...) don't walk the same type forever.
+func collectExternalImports(primary *types.Package, obj types.Object) []importRef {
+	seenPkgs := map[string]bool{primary.Path(): true}
+	seenTypes := map[string]bool{}
+	var refs []importRef
+
+	var walk func(t types.Type)
+	walk = func(t types.Type) {
+		switch v := t.(type) {
+		case *types.Named:
+			key := v.String()
+			if seenTypes[key] {
+				return
+			}
+			seenTypes[key] = true
+
+			if p := v.Obj().Pkg(); p != nil && !seenPkgs[p.Path()] {
+				seenPkgs[p.Path()] = true
+				refs = append(refs, importRef{Path: p.Path(), Alias: p.Name()})
+			}
+			walk(v.Underlying())
+		case *types.Pointer:
+			walk(v.Elem())
+		case *types.Slice:
+			walk(v.Elem())
+		case *types.Array:
+			walk(v.Elem())
+		case *types.Map:
+			walk(v.Key())
+			walk(v.Elem())
+		case *types.Struct:
+			for i := 0; i < v.NumFields(); i++ {
+				walk(v.Field(i).Type())
+			}
+		}
+	}
+
+	walk(obj.Type())
+
+	return refs
+}